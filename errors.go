@@ -0,0 +1,28 @@
+package workgroup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiError collects the errors returned by a Worker configured with
+// Options.CollectErrors.
+type MultiError struct {
+	errs []error
+}
+
+// Error implements the error interface.
+func (m *MultiError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors occurred:", len(m.errs))
+	for _, err := range m.errs {
+		fmt.Fprintf(&b, "\n\t* %s", err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the collected errors so that errors.Is and errors.As can
+// inspect them individually.
+func (m *MultiError) Unwrap() []error {
+	return m.errs
+}
@@ -0,0 +1,64 @@
+package workgroup_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tschaub/workgroup"
+)
+
+func TestWorkerRecoverPanics(t *testing.T) {
+	letters := "abcdefghijklmnopqrstuvwxyz"
+
+	worker := workgroup.New(&workgroup.Options[string]{
+		RecoverPanics: true,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			if data == "f" {
+				panic("boom")
+			}
+			return nil
+		},
+	})
+
+	for i := 0; i < len(letters); i++ {
+		require.NoError(t, worker.Add(letters[i:i+1]))
+	}
+
+	err := worker.Wait()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestWorkerCollectErrors(t *testing.T) {
+	letters := "abcdefghijklmnopqrstuvwxyz"
+
+	errFor := func(letter string) error {
+		return fmt.Errorf("failed on %s", letter)
+	}
+
+	worker := workgroup.New(&workgroup.Options[string]{
+		CollectErrors: true,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			if data == "f" || data == "q" {
+				return errFor(data)
+			}
+			return nil
+		},
+	})
+
+	for i := 0; i < len(letters); i++ {
+		require.NoError(t, worker.Add(letters[i:i+1]))
+	}
+
+	err := worker.Wait()
+	require.Error(t, err)
+
+	var multi *workgroup.MultiError
+	require.True(t, errors.As(err, &multi))
+
+	assert.ErrorContains(t, multi, "failed on f")
+	assert.ErrorContains(t, multi, "failed on q")
+}
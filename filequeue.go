@@ -0,0 +1,379 @@
+package workgroup
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// compactThreshold is the number of acknowledged records a FileQueue
+// accumulates before Ack rotates the log and ack files, so a
+// long-running queue doesn't grow without bound.
+const compactThreshold = 1000
+
+// FileQueue is a Queue implementation that persists pending items to a
+// rotating append-only log file, so a worker process that crashes
+// mid-run can be restarted and pick up where it left off when the same
+// path is reopened.
+//
+// Entries are appended to "<path>.log" as they are added.  Once an entry
+// has been fully processed, its sequence number is appended to
+// "<path>.ack".  When a FileQueue is opened, any log entries that do not
+// have a matching ack are replayed into memory before any new items are
+// added, so no acknowledged work is repeated and no unacknowledged work
+// is lost.  Once enough records have been acknowledged, the log and ack
+// files are rewritten to hold only the records that are still
+// outstanding, so the files on disk stay proportional to the backlog
+// rather than to the queue's entire history.
+type FileQueue[T any] struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	log *os.File
+	ack *os.File
+
+	encode func(T) ([]byte, error)
+	decode func([]byte) (T, error)
+
+	items     []fileRecord
+	pending   []fileRecord
+	nextSeq   uint64
+	submitted int
+	claimed   int
+	acked     int
+	err       error
+}
+
+type fileRecord struct {
+	seq  uint64
+	data []byte
+}
+
+// NewFileQueue opens (creating if necessary) the log and ack files at
+// path, replays any unacknowledged entries into memory, and returns a
+// Queue backed by those files.
+func NewFileQueue[T any](path string, encode func(T) ([]byte, error), decode func([]byte) (T, error)) (*FileQueue[T], error) {
+	log, err := os.OpenFile(path+".log", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file: %w", err)
+	}
+
+	ack, err := os.OpenFile(path+".ack", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Close()
+		return nil, fmt.Errorf("opening ack file: %w", err)
+	}
+
+	q := &FileQueue[T]{
+		log:    log,
+		ack:    ack,
+		encode: encode,
+		decode: decode,
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	if err := q.recover(); err != nil {
+		log.Close()
+		ack.Close()
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// recover reads every record in the log file and every acknowledgment in
+// the ack file, and queues up any record that has not been acknowledged.
+func (q *FileQueue[T]) recover() error {
+	records, err := readRecords(q.log)
+	if err != nil {
+		return fmt.Errorf("reading log file: %w", err)
+	}
+
+	acked, err := readSeqs(q.ack)
+	if err != nil {
+		return fmt.Errorf("reading ack file: %w", err)
+	}
+
+	for _, record := range records {
+		if record.seq >= q.nextSeq {
+			q.nextSeq = record.seq + 1
+		}
+		if !acked[record.seq] {
+			q.items = append(q.items, record)
+		}
+	}
+	q.submitted = len(q.items)
+
+	return nil
+}
+
+// Add encodes item, appends it to the log file, and queues it for
+// processing.
+func (q *FileQueue[T]) Add(item T) error {
+	return q.add(item, false)
+}
+
+// AddClaimed implements Claimer.
+func (q *FileQueue[T]) AddClaimed(item T) error {
+	return q.add(item, true)
+}
+
+func (q *FileQueue[T]) add(item T, claimed bool) error {
+	data, err := q.encode(item)
+	if err != nil {
+		return fmt.Errorf("encoding item: %w", err)
+	}
+
+	q.mu.Lock()
+	seq := q.nextSeq
+	q.nextSeq++
+	if err := writeRecord(q.log, seq, data); err != nil {
+		q.mu.Unlock()
+		return fmt.Errorf("writing log record: %w", err)
+	}
+	q.items = append(q.items, fileRecord{seq: seq, data: data})
+	q.submitted++
+	if claimed {
+		q.claimed++
+	}
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+	return nil
+}
+
+// Deficit implements Claimer.
+func (q *FileQueue[T]) Deficit() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deficit := q.submitted - q.claimed
+	q.claimed += deficit
+	return deficit
+}
+
+// Next removes and decodes the next item from the queue, blocking until
+// an item is available or ctx is done.  If the record cannot be
+// decoded, Next reports no item is available and records the decode
+// error, which is surfaced through Err.
+func (q *FileQueue[T]) Next(ctx context.Context) (T, bool) {
+	var zero T
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.cond.Broadcast()
+		case <-done:
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 {
+		if ctx.Err() != nil {
+			return zero, false
+		}
+		q.cond.Wait()
+	}
+
+	record := q.items[0]
+	q.items = q.items[1:]
+
+	item, err := q.decode(record.data)
+	if err != nil {
+		if q.err == nil {
+			q.err = fmt.Errorf("decoding record %d: %w", record.seq, err)
+		}
+		return zero, false
+	}
+
+	q.pending = append(q.pending, record)
+	return item, true
+}
+
+// Err returns the first error encountered while decoding a record from
+// the log file, if any.  Err implements an error-reporting interface
+// that Worker checks whenever Next reports no item is available, so a
+// corrupt record aborts the worker instead of being mistaken for an
+// empty queue.
+func (q *FileQueue[T]) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// Ack records that the item has been fully processed, so it will not be
+// replayed if the queue is reopened.  Ack matches the item against the
+// encoded record it was produced from, rather than assuming items are
+// acknowledged in the order they were dequeued, since concurrent
+// Workers can finish processing items out of order.  Ack implements the
+// Acker interface, and is called automatically by a Worker after a
+// successful call to Work.
+func (q *FileQueue[T]) Ack(item T) error {
+	data, err := q.encode(item)
+	if err != nil {
+		return fmt.Errorf("encoding item: %w", err)
+	}
+
+	q.mu.Lock()
+	seq, found := uint64(0), false
+	for i, record := range q.pending {
+		if bytes.Equal(record.data, data) {
+			seq = record.seq
+			found = true
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			break
+		}
+	}
+	if !found {
+		q.mu.Unlock()
+		return errors.New("no pending item to acknowledge")
+	}
+	q.mu.Unlock()
+
+	if err := writeSeq(q.ack, seq); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.acked++
+	compact := q.acked >= compactThreshold
+	q.mu.Unlock()
+
+	if compact {
+		return q.compact()
+	}
+	return nil
+}
+
+// compact rewrites the log and ack files to hold only the records that
+// are still outstanding -- queued or dequeued but not yet acknowledged
+// -- so the files on disk stay proportional to the backlog rather than
+// to the queue's entire history.
+func (q *FileQueue[T]) compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	retained := make([]fileRecord, 0, len(q.pending)+len(q.items))
+	retained = append(retained, q.pending...)
+	retained = append(retained, q.items...)
+
+	if err := q.log.Truncate(0); err != nil {
+		return fmt.Errorf("truncating log file: %w", err)
+	}
+	if _, err := q.log.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking log file: %w", err)
+	}
+	for _, record := range retained {
+		if err := writeRecord(q.log, record.seq, record.data); err != nil {
+			return fmt.Errorf("writing log record: %w", err)
+		}
+	}
+
+	if err := q.ack.Truncate(0); err != nil {
+		return fmt.Errorf("truncating ack file: %w", err)
+	}
+	if _, err := q.ack.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking ack file: %w", err)
+	}
+
+	q.acked = 0
+	return nil
+}
+
+// Backlog returns the number of items currently queued, including any
+// unacknowledged items replayed when the queue was opened.
+func (q *FileQueue[T]) Backlog() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Close closes the underlying log and ack files.
+func (q *FileQueue[T]) Close() error {
+	logErr := q.log.Close()
+	ackErr := q.ack.Close()
+	if logErr != nil {
+		return logErr
+	}
+	return ackErr
+}
+
+func writeRecord(f *os.File, seq uint64, data []byte) error {
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint64(header[:8], seq)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(data)))
+
+	if _, err := f.Write(header); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func readRecords(f *os.File) ([]fileRecord, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var records []fileRecord
+	header := make([]byte, 12)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		seq := binary.BigEndian.Uint64(header[:8])
+		size := binary.BigEndian.Uint32(header[8:])
+		data := make([]byte, size)
+		if _, err := io.ReadFull(f, data); err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecord{seq: seq, data: data})
+	}
+
+	_, err := f.Seek(0, io.SeekEnd)
+	return records, err
+}
+
+func writeSeq(f *os.File, seq uint64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, seq)
+	if _, err := f.Write(buf); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func readSeqs(f *os.File) (map[uint64]bool, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	acked := map[uint64]bool{}
+	buf := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(f, buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		acked[binary.BigEndian.Uint64(buf)] = true
+	}
+
+	_, err := f.Seek(0, io.SeekEnd)
+	return acked, err
+}
@@ -0,0 +1,207 @@
+package workgroup_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tschaub/workgroup"
+)
+
+func encodeString(value string) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func decodeString(data []byte) (string, error) {
+	var value string
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+func TestFileQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+
+	queue, err := workgroup.NewFileQueue(path, encodeString, decodeString)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	visited := sync.Map{}
+	letters := "abcdefghijklmnopqrstuvwxyz"
+
+	worker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			visited.Store(data, true)
+			return nil
+		},
+	})
+
+	for i := 0; i < len(letters); i++ {
+		require.NoError(t, worker.Add(letters[i:i+1]))
+	}
+
+	require.NoError(t, worker.Wait())
+
+	for i := 0; i < len(letters); i++ {
+		_, ok := visited.Load(letters[i : i+1])
+		assert.True(t, ok)
+	}
+}
+
+func TestFileQueueRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+
+	queue, err := workgroup.NewFileQueue(path, encodeString, decodeString)
+	require.NoError(t, err)
+
+	require.NoError(t, queue.Add("a"))
+	require.NoError(t, queue.Add("b"))
+	require.NoError(t, queue.Add("c"))
+
+	// simulate a worker dequeuing and acknowledging a single item before
+	// the process crashes
+	value, ok := queue.Next(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, "a", value)
+	require.NoError(t, queue.Ack(value))
+	require.NoError(t, queue.Close())
+
+	reopened, err := workgroup.NewFileQueue(path, encodeString, decodeString)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	visited := sync.Map{}
+	worker := workgroup.New(&workgroup.Options[string]{
+		Queue: reopened,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			visited.Store(data, true)
+			return nil
+		},
+	})
+
+	require.NoError(t, worker.Wait())
+
+	_, ok = visited.Load("a")
+	assert.False(t, ok, "acknowledged item should not be replayed")
+
+	_, ok = visited.Load("b")
+	assert.True(t, ok, "unacknowledged item should be replayed")
+
+	_, ok = visited.Load("c")
+	assert.True(t, ok, "unacknowledged item should be replayed")
+}
+
+func TestFileQueueAckOutOfOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+
+	queue, err := workgroup.NewFileQueue(path, encodeString, decodeString)
+	require.NoError(t, err)
+
+	require.NoError(t, queue.Add("first"))
+	require.NoError(t, queue.Add("second"))
+
+	first, ok := queue.Next(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, "first", first)
+
+	second, ok := queue.Next(context.Background())
+	require.True(t, ok)
+	assert.Equal(t, "second", second)
+
+	// acknowledge the later-dequeued item first, as a worker pool with
+	// more than one goroutine finishing items out of order would
+	require.NoError(t, queue.Ack(second))
+	require.NoError(t, queue.Close())
+
+	reopened, err := workgroup.NewFileQueue(path, encodeString, decodeString)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	visited := sync.Map{}
+	worker := workgroup.New(&workgroup.Options[string]{
+		Queue: reopened,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			visited.Store(data, true)
+			return nil
+		},
+	})
+
+	require.NoError(t, worker.Wait())
+
+	_, ok = visited.Load("first")
+	assert.True(t, ok, "item that was never acknowledged should be replayed even though a later item was")
+
+	_, ok = visited.Load("second")
+	assert.False(t, ok, "item acknowledged out of turn should not be replayed")
+}
+
+func TestFileQueueDecodeError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+
+	decodeErr := errors.New("boom")
+	failDecode := func(data []byte) (string, error) {
+		value, err := decodeString(data)
+		if err != nil {
+			return "", err
+		}
+		if value == "bad" {
+			return "", decodeErr
+		}
+		return value, nil
+	}
+
+	queue, err := workgroup.NewFileQueue(path, encodeString, failDecode)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	require.NoError(t, queue.Add("bad"))
+
+	worker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			return nil
+		},
+	})
+
+	err = worker.Wait()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, decodeErr)
+}
+
+func TestFileQueueCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+
+	queue, err := workgroup.NewFileQueue(path, encodeString, decodeString)
+	require.NoError(t, err)
+	defer queue.Close()
+
+	worker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			return nil
+		},
+	})
+
+	const total = 1500
+	for i := 0; i < total; i++ {
+		require.NoError(t, worker.Add(fmt.Sprintf("item-%d", i)))
+	}
+	require.NoError(t, worker.Wait())
+
+	info, err := os.Stat(path + ".log")
+	require.NoError(t, err)
+
+	// a fully acknowledged log entry is at least 12 bytes of header plus
+	// its encoded data; compaction runs once 1000 records have been
+	// acknowledged, so the log ends up holding only the records that were
+	// still outstanding then (about a third of this run) rather than
+	// growing with the full run's history
+	assert.Less(t, info.Size(), int64(total)*12)
+}
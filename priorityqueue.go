@@ -0,0 +1,129 @@
+package workgroup
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// NewPriorityQueue creates a Queue that dequeues items in order of
+// decreasing priority, as reported by the given priority function.
+// Items with equal priority are dequeued in the order they were added.
+func NewPriorityQueue[T any](priority func(T) int) Queue[T] {
+	q := &priorityQueue[T]{priority: priority}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+type priorityItem[T any] struct {
+	value T
+	order int
+}
+
+type priorityQueue[T any] struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	items     []priorityItem[T]
+	seq       int
+	submitted int
+	claimed   int
+
+	priority func(T) int
+}
+
+// Len, Less, Swap, Push, and Pop implement heap.Interface.  Callers must
+// hold q.mu while invoking them through the heap package.
+
+func (q *priorityQueue[T]) Len() int {
+	return len(q.items)
+}
+
+func (q *priorityQueue[T]) Less(i, j int) bool {
+	pi, pj := q.priority(q.items[i].value), q.priority(q.items[j].value)
+	if pi != pj {
+		return pi > pj
+	}
+	return q.items[i].order < q.items[j].order
+}
+
+func (q *priorityQueue[T]) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+func (q *priorityQueue[T]) Push(x any) {
+	q.items = append(q.items, x.(priorityItem[T]))
+}
+
+func (q *priorityQueue[T]) Pop() any {
+	old := q.items
+	n := len(old)
+	item := old[n-1]
+	q.items = old[:n-1]
+	return item
+}
+
+// Backlog returns the number of items currently queued.
+func (q *priorityQueue[T]) Backlog() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *priorityQueue[T]) Add(item T) error {
+	q.mu.Lock()
+	heap.Push(q, priorityItem[T]{value: item, order: q.seq})
+	q.seq++
+	q.submitted++
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *priorityQueue[T]) AddClaimed(item T) error {
+	q.mu.Lock()
+	heap.Push(q, priorityItem[T]{value: item, order: q.seq})
+	q.seq++
+	q.submitted++
+	q.claimed++
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+	return nil
+}
+
+// Deficit implements Claimer.
+func (q *priorityQueue[T]) Deficit() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deficit := q.submitted - q.claimed
+	q.claimed += deficit
+	return deficit
+}
+
+func (q *priorityQueue[T]) Next(ctx context.Context) (T, bool) {
+	var zero T
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.cond.Broadcast()
+		case <-done:
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.Len() == 0 {
+		if ctx.Err() != nil {
+			return zero, false
+		}
+		q.cond.Wait()
+	}
+
+	item := heap.Pop(q).(priorityItem[T])
+	return item.value, true
+}
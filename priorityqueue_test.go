@@ -0,0 +1,226 @@
+package workgroup_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tschaub/workgroup"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	queue := workgroup.NewPriorityQueue(func(value int) int {
+		return value
+	})
+
+	for _, value := range []int{3, 1, 4, 1, 5, 9, 2, 6} {
+		require.NoError(t, queue.Add(value))
+	}
+
+	var order []int
+	mu := sync.Mutex{}
+
+	worker := workgroup.New(&workgroup.Options[int]{
+		Queue: queue,
+		Limit: 1,
+		Work: func(w *workgroup.Worker[int], data int) error {
+			mu.Lock()
+			order = append(order, data)
+			mu.Unlock()
+			return nil
+		},
+	})
+
+	require.NoError(t, worker.Wait())
+
+	assert.Equal(t, []int{9, 6, 5, 4, 3, 2, 1, 1}, order)
+}
+
+func TestWorkerKeyDedup(t *testing.T) {
+	visited := sync.Map{}
+	count := atomic.Int64{}
+
+	worker := workgroup.New(&workgroup.Options[string]{
+		Key: func(data string) string {
+			return data
+		},
+		Work: func(w *workgroup.Worker[string], data string) error {
+			visited.Store(data, true)
+			count.Add(1)
+
+			if len(data) > 0 {
+				// re-discover the same node repeatedly, as a crawl might
+				// when following links back to pages it has already seen
+				if err := w.Add(data[1:]); err != nil {
+					return err
+				}
+				if err := w.Add(data[1:]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+
+	require.NoError(t, worker.Add("abc"))
+	require.NoError(t, worker.Add("abc"))
+	require.NoError(t, worker.Wait())
+
+	for _, key := range []string{"abc", "bc", "c", ""} {
+		_, ok := visited.Load(key)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, int64(4), count.Load())
+}
+
+func TestWorkersSharedPriorityQueue(t *testing.T) {
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	next := make(chan struct{})
+
+	queue := workgroup.NewPriorityQueue(func(value string) int {
+		return len(value)
+	})
+
+	visited := sync.Map{}
+	letters := "abcdefghijklmnopqrstuvwxyz"
+
+	firstWorker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			if _, exists := visited.LoadOrStore(data, true); exists {
+				return fmt.Errorf("duplicate: %s", data)
+			}
+
+			if len(data) < len(letters)/2 {
+				// stop doing work to simulate a worker getting taken down
+				for {
+					select {
+					case next <- struct{}{}:
+						// start the next worker
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			}
+
+			return nil
+		},
+	})
+
+	secondWorker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			if _, exists := visited.LoadOrStore(data, true); exists {
+				return fmt.Errorf("duplicate: %s", data)
+			}
+
+			return nil
+		},
+	})
+
+	for i := 0; i < len(letters); i++ {
+		require.NoError(t, firstWorker.Add(letters[0:len(letters)-i]))
+	}
+
+	group := errgroup.Group{}
+	group.Go(func() error {
+		return firstWorker.Wait()
+	})
+
+	group.Go(func() error {
+		<-next
+		err := secondWorker.Wait()
+		if err != nil {
+			return err
+		}
+		done()
+		return nil
+	})
+
+	assert.NoError(t, group.Wait())
+
+	for i := 1; i < len(letters); i++ {
+		_, ok := visited.Load(letters[0:i])
+		assert.True(t, ok)
+	}
+}
+
+func TestWorkersSharedKeyDedupQueue(t *testing.T) {
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	next := make(chan struct{})
+
+	queue := workgroup.NewDefaultQueue[string]()
+	key := func(data string) string {
+		return data
+	}
+
+	count := atomic.Int64{}
+	letters := "abcdefghijklmnopqrstuvwxyz"
+
+	firstWorker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Key:   key,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			count.Add(1)
+
+			if len(data) < len(letters)/2 {
+				// stop doing work to simulate a worker getting taken down
+				for {
+					select {
+					case next <- struct{}{}:
+						// start the next worker
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			}
+
+			return nil
+		},
+	})
+
+	secondWorker := workgroup.New(&workgroup.Options[string]{
+		Queue: queue,
+		Key:   key,
+		Work: func(w *workgroup.Worker[string], data string) error {
+			count.Add(1)
+			return nil
+		},
+	})
+
+	for i := 0; i < len(letters); i++ {
+		prefix := letters[0 : len(letters)-i]
+		require.NoError(t, firstWorker.Add(prefix))
+		// re-add the same key to confirm it is dropped rather than queued
+		// a second time, even with a second worker sharing the queue
+		require.NoError(t, firstWorker.Add(prefix))
+	}
+
+	group := errgroup.Group{}
+	group.Go(func() error {
+		return firstWorker.Wait()
+	})
+
+	group.Go(func() error {
+		<-next
+		err := secondWorker.Wait()
+		if err != nil {
+			return err
+		}
+		done()
+		return nil
+	})
+
+	assert.NoError(t, group.Wait())
+	assert.Equal(t, int64(len(letters)), count.Load())
+}
@@ -0,0 +1,140 @@
+package workgroup
+
+import (
+	"context"
+	"sync"
+)
+
+// Queue stores values for a Worker to process.  Implementations must be
+// safe for concurrent use.
+type Queue[T any] interface {
+	// Add appends an item to the queue.
+	Add(item T) error
+
+	// Next removes and returns the next item from the queue.  Next
+	// blocks until an item is available or ctx is done, in which case ok
+	// is false.
+	Next(ctx context.Context) (item T, ok bool)
+}
+
+// Acker is implemented by queues that can acknowledge completed work,
+// such as the queue returned by NewFileQueue.  If a Worker's Queue
+// implements Acker, Ack is called with the item after a successful call
+// to Work.
+type Acker[T any] interface {
+	Ack(item T) error
+}
+
+// Claimer is implemented by queues that track, across every Worker that
+// shares them, how many items have been added and how many goroutines
+// have already been started to dequeue one.  A Worker calls AddClaimed
+// instead of Add when its Queue implements Claimer, and Wait calls
+// Deficit to find out how many additional goroutines it needs to start
+// to drain items that nobody has claimed yet -- for example items
+// already queued before the Worker was created, or items left behind
+// when another Worker sharing the queue stops making progress.
+//
+// Deficit is computed and reserved atomically with AddClaimed, so a
+// Worker never starts more goroutines than there are items for them to
+// dequeue, which would otherwise block forever waiting on a Queue that
+// has nothing left to give them.
+type Claimer[T any] interface {
+	// AddClaimed behaves like Add, but also records that a goroutine
+	// has been started to dequeue the item.
+	AddClaimed(item T) error
+
+	// Deficit reports how many items have been added across every
+	// Worker sharing the queue but not yet matched by a call to
+	// AddClaimed, and atomically counts them as claimed.
+	Deficit() int
+}
+
+// Errorer is implemented by queues that can report an error unrelated to
+// any particular call to Work, such as a corrupt record encountered
+// while decoding an item in a queue returned by NewFileQueue.  If a
+// Worker's Queue implements Errorer, Err is checked whenever Next
+// reports that no item is available, and any non-nil error is treated
+// the same as an error returned from Work.
+type Errorer interface {
+	Err() error
+}
+
+// NewDefaultQueue creates a simple in-memory, first-in-first-out Queue.
+func NewDefaultQueue[T any]() Queue[T] {
+	q := &defaultQueue[T]{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+type defaultQueue[T any] struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	items     []T
+	submitted int
+	claimed   int
+}
+
+// Backlog returns the number of items currently queued.
+func (q *defaultQueue[T]) Backlog() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *defaultQueue[T]) Add(item T) error {
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.submitted++
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *defaultQueue[T]) AddClaimed(item T) error {
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.submitted++
+	q.claimed++
+	q.mu.Unlock()
+
+	q.cond.Broadcast()
+	return nil
+}
+
+// Deficit implements Claimer.
+func (q *defaultQueue[T]) Deficit() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deficit := q.submitted - q.claimed
+	q.claimed += deficit
+	return deficit
+}
+
+func (q *defaultQueue[T]) Next(ctx context.Context) (T, bool) {
+	var zero T
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.cond.Broadcast()
+		case <-done:
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 {
+		if ctx.Err() != nil {
+			return zero, false
+		}
+		q.cond.Wait()
+	}
+
+	item := q.items[0]
+	q.items = q.items[1:]
+	return item, true
+}
@@ -0,0 +1,281 @@
+// Package workgroup provides a bounded pool of goroutines for processing a
+// dynamic, potentially recursive, stream of work.
+//
+// Work is scheduled with the Worker's Add method, which may be called
+// before Wait and may also be called from within a Work function to
+// schedule additional work.  Wait blocks until all scheduled work has
+// completed or a Work function returns an error, and also returns early
+// if the context is canceled before all scheduled work could run.
+package workgroup
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Options configure a new Worker.
+type Options[T any] struct {
+	// Context, if provided, is used to control cancellation of the
+	// worker.  If not provided, context.Background() is used.
+	Context context.Context
+
+	// Limit sets the maximum number of goroutines used to process work
+	// concurrently.  A zero or negative Limit means no limit is applied.
+	Limit int
+
+	// Queue stores work before it is processed.  If not provided, a new
+	// queue is created with NewDefaultQueue, unless Priority is set, in
+	// which case NewPriorityQueue is used.
+	Queue Queue[T]
+
+	// Priority, if provided, causes items to be dequeued in order of
+	// decreasing priority instead of in the order they were added.  It
+	// is ignored if Queue is provided.
+	Priority func(data T) int
+
+	// Key, if provided, is called for each item passed to Add.  If the
+	// key has already been seen by this worker, the item is silently
+	// dropped instead of being queued again.
+	Key func(data T) string
+
+	// RecoverPanics, if true, recovers panics raised from Work and
+	// converts them into an error that includes the recovered value and
+	// a stack trace, instead of letting the panic crash the process.
+	RecoverPanics bool
+
+	// CollectErrors, if true, lets the worker keep processing queued
+	// work after Work returns an error instead of aborting, and Wait
+	// returns a *MultiError with every error that occurred instead of
+	// just the first one.
+	CollectErrors bool
+
+	// Work is called for each item added to the worker.  Work may call
+	// the Worker's Add method to schedule additional work.
+	Work func(w *Worker[T], data T) error
+}
+
+// Worker processes work added with Add.
+type Worker[T any] struct {
+	ctx           context.Context
+	cancel        context.CancelFunc
+	queue         Queue[T]
+	work          func(w *Worker[T], data T) error
+	limit         chan struct{}
+	key           func(data T) string
+	seen          sync.Map
+	recoverPanics bool
+	collectErrors bool
+	wg            sync.WaitGroup
+
+	// abandoned counts goroutines that were started to dequeue a claimed
+	// item but bailed out because the context was canceled before that
+	// item could be run, so Wait can tell a cancellation that actually
+	// cost some work from one that merely happened to land after
+	// everything finished.
+	abandoned atomic.Int64
+
+	mu   sync.Mutex
+	err  error
+	errs []error
+}
+
+// New creates a Worker configured with the provided options.
+func New[T any](options *Options[T]) *Worker[T] {
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	queue := options.Queue
+	if queue == nil {
+		if options.Priority != nil {
+			queue = NewPriorityQueue(options.Priority)
+		} else {
+			queue = NewDefaultQueue[T]()
+		}
+	}
+
+	var limit chan struct{}
+	if options.Limit > 0 {
+		limit = make(chan struct{}, options.Limit)
+	}
+
+	return &Worker[T]{
+		ctx:           ctx,
+		cancel:        cancel,
+		queue:         queue,
+		work:          options.Work,
+		limit:         limit,
+		key:           options.Key,
+		recoverPanics: options.RecoverPanics,
+		collectErrors: options.CollectErrors,
+	}
+}
+
+// Context returns the context associated with the worker.
+func (w *Worker[T]) Context() context.Context {
+	return w.ctx
+}
+
+// Add schedules data to be processed.  Add may be called before Wait and
+// from within a Work function to schedule additional work.
+//
+// If Options.Key was provided, data is silently dropped when its key has
+// already been added during this worker's lifetime.
+func (w *Worker[T]) Add(data T) error {
+	if w.key != nil {
+		key := w.key(data)
+		if _, seen := w.seen.LoadOrStore(key, struct{}{}); seen {
+			return nil
+		}
+	}
+
+	if claimer, ok := w.queue.(Claimer[T]); ok {
+		// AddClaimed records, atomically with queueing the item, that
+		// the goroutine started below is the one that will dequeue it,
+		// so a concurrent call to Wait's Deficit check never mistakes
+		// this item for one nobody is about to claim.
+		if err := claimer.AddClaimed(data); err != nil {
+			return err
+		}
+	} else if err := w.queue.Add(data); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go w.process()
+	return nil
+}
+
+// process acquires a slot (if the worker is limited), dequeues a single
+// item, and runs the configured Work function against it.
+func (w *Worker[T]) process() {
+	defer w.wg.Done()
+
+	if w.limit != nil {
+		select {
+		case w.limit <- struct{}{}:
+			defer func() { <-w.limit }()
+		case <-w.ctx.Done():
+			// the claimed item behind this goroutine never got a chance
+			// to be dequeued.
+			w.abandoned.Add(1)
+			return
+		}
+	}
+
+	data, ok := w.queue.Next(w.ctx)
+	if !ok {
+		if errorer, ok := w.queue.(Errorer); ok {
+			if err := errorer.Err(); err != nil {
+				w.fail(err)
+				return
+			}
+		}
+		// Next only reports no item available when the context has been
+		// canceled, so the claimed item behind this goroutine was
+		// abandoned rather than drained.
+		w.abandoned.Add(1)
+		return
+	}
+
+	if err := w.run(data); err != nil {
+		w.fail(err)
+		return
+	}
+
+	if acker, ok := w.queue.(Acker[T]); ok {
+		if err := acker.Ack(data); err != nil {
+			w.fail(err)
+		}
+	}
+}
+
+// run calls Work, recovering and converting any panic into an error if
+// the worker was configured with Options.RecoverPanics.
+func (w *Worker[T]) run(data T) (err error) {
+	if w.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+	}
+
+	return w.work(w, data)
+}
+
+func (w *Worker[T]) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.collectErrors {
+		w.errs = append(w.errs, err)
+		return
+	}
+
+	if w.err == nil {
+		w.err = err
+		w.cancel()
+	}
+}
+
+// Wait blocks until all scheduled work has completed or a Work function
+// returns an error.  The first non-nil error encountered is returned.
+//
+// If the context is canceled before every claimed item could be
+// dequeued and run, Wait returns the context's error even if no Work
+// function itself returned one.  A cancellation that merely happens to
+// land after every claimed item has already finished does not count;
+// Wait returns nil in that case.
+//
+// If the worker was configured with Options.CollectErrors, Wait instead
+// lets every queued item finish before returning, and returns a
+// *MultiError with every error that occurred.  If none did, Wait returns
+// nil, or the context's error under the cancellation rule above.
+//
+// If the worker's queue already holds items that were not added through
+// this worker's Add method (for example, items replayed by a queue
+// returned from NewFileQueue, or items left behind by another worker
+// sharing the same queue), Wait starts enough additional goroutines to
+// drain them.  This only works if the queue implements Claimer; the
+// queues returned by NewDefaultQueue, NewPriorityQueue, and
+// NewFileQueue all do.
+func (w *Worker[T]) Wait() error {
+	if claimer, ok := w.queue.(Claimer[T]); ok {
+		if deficit := claimer.Deficit(); deficit > 0 {
+			w.wg.Add(deficit)
+			for i := 0; i < deficit; i++ {
+				go w.process()
+			}
+		}
+	}
+
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.collectErrors {
+		if len(w.errs) == 0 {
+			if w.abandoned.Load() > 0 {
+				return w.ctx.Err()
+			}
+			return nil
+		}
+		return &MultiError{errs: w.errs}
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.abandoned.Load() > 0 {
+		return w.ctx.Err()
+	}
+	return nil
+}